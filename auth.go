@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -8,55 +10,86 @@ import (
 
 	"github.com/gorilla/securecookie"
 	"github.com/stretchr/gomniauth"
+	"github.com/stretchr/gomniauth/common"
 	"github.com/stretchr/gomniauth/providers/github"
+	"github.com/stretchr/gomniauth/providers/gitlab"
 	"github.com/stretchr/gomniauth/providers/google"
 	"github.com/stretchr/goweb"
 	"github.com/stretchr/goweb/context"
+
+	"github.com/smashwilson/collaborative-fiction/session"
 )
 
-var cookieGen *securecookie.SecureCookie
+// providerConstructors maps an [oauth.<name>] config section to the gomniauth constructor that
+// builds it. Adding a new provider here (and to providerDisplayNames) is enough for operators to
+// enable it from app.ini alone.
+var providerConstructors = map[string]func(clientID, clientSecret, redirectURL string) common.Provider{
+	"google": func(id, secret, redirect string) common.Provider { return google.New(id, secret, redirect) },
+	"github": func(id, secret, redirect string) common.Provider { return github.New(id, secret, redirect) },
+	"gitlab": func(id, secret, redirect string) common.Provider { return gitlab.New(id, secret, redirect) },
+}
 
-const (
-	userCookieName = "user"
-	nameKey        = "name"
-	emailKey       = "email"
-	avatarKey      = "avatar"
-)
+// providerDisplayNames gives the login template a human-readable label for each provider.
+var providerDisplayNames = map[string]string{
+	"google": "Google",
+	"github": "GitHub",
+	"gitlab": "GitLab",
+}
 
-func decodeCookieData(ctx context.Context, key string) (string, error) {
-	cookie, err := ctx.HttpRequest().Cookie(userCookieName)
+// ErrNotSignedIn is returned by UserName/UserEmail/UserAvatar when no user is signed in.
+var ErrNotSignedIn = errors.New("no user is signed in")
+
+func currentUser(ctx context.Context) (*session.User, error) {
+	user, err := session.CurrentUser(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	if user == nil {
+		return nil, ErrNotSignedIn
+	}
+	return user, nil
+}
 
-	cookieData := make(map[string]string)
-	err = cookieGen.Decode(userCookieName, cookie.Value, &cookieData)
+// UserName extracts the username from an authenticated session, or an error if no user is
+// signed in.
+func UserName(ctx context.Context) (string, error) {
+	user, err := currentUser(ctx)
 	if err != nil {
 		return "", err
 	}
-
-	return cookieData[key], nil
+	return user.Name, nil
 }
 
-// UserName extracts the username from an authenticated session, or "" if no user is logged in.
-func UserName(ctx context.Context) (string, error) {
-	return decodeCookieData(ctx, nameKey)
+// UserEmail extracts the email address from an authenticated session, or an error if no user is
+// signed in.
+func UserEmail(ctx context.Context) (string, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	return user.Email, nil
 }
 
-// UserAvatar returns the URL to an authenticated user's avatar, or "" if no user is logged in.
+// UserAvatar returns the URL to an authenticated user's avatar, or an error if no user is signed
+// in.
 func UserAvatar(ctx context.Context) (string, error) {
-	return decodeCookieData(ctx, avatarKey)
+	user, err := currentUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	return user.Avatar, nil
 }
 
+// securityKey reads the key stored at filename, generating and persisting a random one of
+// length bytes the first time it's needed.
 func securityKey(filename string, length int) ([]byte, error) {
-	file, err := os.Open(filename)
+	data, err := ioutil.ReadFile(filename)
 	switch {
-	case err != nil:
-		return ioutil.ReadAll(file)
+	case err == nil:
+		return data, nil
 	case os.IsNotExist(err):
 		secret := securecookie.GenerateRandomKey(length)
-		err := ioutil.WriteFile(filename, secret, 0600)
-		if err != nil {
+		if err := ioutil.WriteFile(filename, secret, 0600); err != nil {
 			return nil, err
 		}
 		return secret, nil
@@ -65,33 +98,70 @@ func securityKey(filename string, length int) ([]byte, error) {
 	}
 }
 
+// sessionKeyPairs returns the ordered (hash, block) key pairs used to sign and encrypt
+// sessions, flattened for gorilla/sessions.NewCookieStore. Pair 0 is read from (or generated
+// into) ".sessionkey.0.hash"/".sessionkey.0.block" and is used for new sessions. Operators
+// rotate keys by moving the current pair aside to ".sessionkey.1.hash"/".sessionkey.1.block"
+// (and so on) before letting collaborative-fiction generate a fresh pair 0; older pairs are
+// still accepted until they're removed.
+func sessionKeyPairs() ([][]byte, error) {
+	var pairs [][]byte
+
+	for i := 0; ; i++ {
+		hashFile := fmt.Sprintf(".sessionkey.%d.hash", i)
+		blockFile := fmt.Sprintf(".sessionkey.%d.block", i)
+
+		if i > 0 {
+			if _, err := os.Stat(hashFile); os.IsNotExist(err) {
+				break
+			}
+		}
+
+		hashKey, err := securityKey(hashFile, 64)
+		if err != nil {
+			return nil, err
+		}
+		blockKey, err := securityKey(blockFile, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs = append(pairs, hashKey, blockKey)
+	}
+
+	return pairs, nil
+}
+
 func registerAuthRoutes() error {
 	providerSecret, err := securityKey(".provider.secret", 64)
 	if err != nil {
 		return err
 	}
+	gomniauth.SetSecurityKey(string(providerSecret))
 
-	cookieHash, err := securityKey(".cookiehash.secret", 64)
+	keyPairs, err := sessionKeyPairs()
 	if err != nil {
 		return err
 	}
+	session.Init(keyPairs...)
+
+	providers := make([]common.Provider, 0, len(config.OAuthProviders))
+	for _, name := range config.EnabledProviders() {
+		newProvider, ok := providerConstructors[name]
+		if !ok {
+			log.Printf("No gomniauth implementation registered for OAuth provider [%s]; skipping", name)
+			continue
+		}
 
-	cookieBlock, err := securityKey(".cookieblock.secret", 32)
-	if err != nil {
-		return err
+		oauthConfig := config.OAuthProviders[name]
+		callback := absURL(fmt.Sprintf("auth/%s/callback", name))
+		providers = append(providers, newProvider(oauthConfig.ClientID, oauthConfig.ClientSecret, callback))
 	}
-
-	gomniauth.SetSecurityKey(string(providerSecret))
-
-	cookieGen = securecookie.New(cookieHash, cookieBlock)
-
-	gomniauth.WithProviders(
-		google.New(config.GoogleKey, config.GoogleSecret, absURL("auth/google/callback")),
-		github.New(config.GitHubKey, config.GitHubSecret, absURL("auth/github/callback")),
-	)
+	gomniauth.WithProviders(providers...)
 
 	goweb.Map("GET", path("auth/{provider}/login"), authLoginHandler)
 	goweb.Map("GET", path("auth/{provider}/callback"), authCallbackHandler)
+	goweb.Map("POST", path("auth/logout"), authLogoutHandler)
 
 	return nil
 }
@@ -129,28 +199,38 @@ func authCallbackHandler(ctx context.Context) error {
 		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
 	}
 
-	user, err := provider.GetUser(creds)
+	providerUser, err := provider.GetUser(creds)
 	if err != nil {
 		log.Printf("Unable to retrieve user from provider [%s] results: %v", providerName, err)
 		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
 	}
 
-	cookieData := map[string]string{
-		nameKey:   user.Name(),
-		emailKey:  user.Email(),
-		avatarKey: user.AvatarURL(),
+	user := &session.User{
+		Name:   providerUser.Name(),
+		Email:  providerUser.Email(),
+		Avatar: providerUser.AvatarURL(),
 	}
-	encoded, err := cookieGen.Encode(userCookieName, cookieData)
-	if err != nil {
-		log.Printf("Unable to generate cookie: %v", err)
+	if err := session.SignIn(ctx, user); err != nil {
+		log.Printf("Unable to sign in [%s]: %v", user.Name, err)
 		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
 	}
 
-	http.SetCookie(ctx.HttpResponseWriter(), &http.Cookie{
-		Name:  userCookieName,
-		Value: encoded,
-		Path:  config.Root,
-	})
+	return goweb.Respond.WithRedirect(ctx, config.Root)
+}
+
+func authLogoutHandler(ctx context.Context) error {
+	valid, err := session.ValidateCSRF(ctx, ctx.HttpRequest().FormValue("csrf_token"))
+	if err != nil {
+		log.Printf("Unable to validate CSRF token: %v", err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
+	}
+	if !valid {
+		return goweb.Respond.WithStatus(ctx, http.StatusForbidden)
+	}
 
+	if err := session.SignOut(ctx); err != nil {
+		log.Printf("Unable to sign out: %v", err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
+	}
 	return goweb.Respond.WithRedirect(ctx, config.Root)
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/smashwilson/collaborative-fiction/models"
+)
+
+// dumpCommand exports one or more Stories as a zip archive: a Markdown file per Snippet, a
+// story.json manifest describing ordering and timing, and a users.json listing participating
+// authors. It enumerates Story IDs up front and loads (and writes) one Story at a time, so it
+// never holds more than one Story's worth of content in memory at once.
+var dumpCommand = &cli.Command{
+	Name:  "dump",
+	Usage: "export one or more Stories as a zip archive",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "story-id", Usage: "export only the Story with this ID"},
+		&cli.BoolFlag{Name: "all", Usage: "export every Story"},
+		&cli.StringFlag{Name: "out", Usage: "destination zip file", Required: true},
+	},
+	Action: runDump,
+}
+
+func runDump(c *cli.Context) error {
+	storyID := c.String("story-id")
+	all := c.Bool("all")
+
+	switch {
+	case storyID == "" && !all:
+		return cli.Exit("dump: specify --story-id or --all", 1)
+	case storyID != "" && all:
+		return cli.Exit("dump: --story-id and --all are mutually exclusive", 1)
+	}
+
+	repo, _, err := loadRepository()
+	if err != nil {
+		return err
+	}
+
+	var storyIDs []string
+	if all {
+		storyIDs, err = repo.ListStoryIDs("", 0, 0)
+	} else {
+		storyIDs = []string{storyID}
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(c.String("out"))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	defer archive.Close()
+
+	for _, id := range storyIDs {
+		story, err := repo.LoadStory(id)
+		if err != nil {
+			return fmt.Errorf("dump: story %s: %w", id, err)
+		}
+		if err := dumpStory(archive, story); err != nil {
+			return fmt.Errorf("dump: story %s: %w", story.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// storyManifest is the story.json entry in a dump archive: enough to reconstruct ordering and
+// timing without re-parsing every snippet file.
+type storyManifest struct {
+	ID       string     `json:"id"`
+	Started  *time.Time `json:"started"`
+	Finished *time.Time `json:"finished"`
+	Snippets []string   `json:"snippets"`
+}
+
+func dumpStory(archive *zip.Writer, story *models.Story) error {
+	prefix := story.ID + "/"
+	manifest := storyManifest{ID: story.ID, Started: story.Started, Finished: story.Finished}
+	authors := make(map[string]bool)
+
+	for i, snippet := range story.Snippets {
+		name := fmt.Sprintf("snippet-%03d.md", i)
+		manifest.Snippets = append(manifest.Snippets, name)
+		authors[snippet.Author] = true
+
+		entry, err := archive.Create(prefix + name)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(entry, "---\nauthor: %s\ncreated: %s\n---\n\n", snippet.Author, snippet.Created.Format(time.RFC3339))
+		if snippet.Content != nil {
+			if _, err := io.WriteString(entry, *snippet.Content); err != nil {
+				return err
+			}
+		}
+	}
+
+	manifestEntry, err := archive.Create(prefix + "story.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(manifestEntry).Encode(manifest); err != nil {
+		return err
+	}
+
+	users := make([]string, 0, len(authors))
+	for author := range authors {
+		users = append(users, author)
+	}
+	sort.Strings(users)
+
+	usersEntry, err := archive.Create(prefix + "users.json")
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(usersEntry).Encode(users)
+}
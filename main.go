@@ -4,31 +4,26 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 
-	"github.com/kelseyhightower/envconfig"
 	"github.com/stretchr/goweb"
 	"github.com/stretchr/goweb/context"
-)
+	"github.com/urfave/cli/v2"
 
-// Configuration contains application settings and secrets acquired from the environment.
-type Configuration struct {
-	BaseURL      string
-	GoogleKey    string
-	GoogleSecret string
-	GitHubKey    string
-	GitHubSecret string
-	Root         string
-}
+	"github.com/smashwilson/collaborative-fiction/session"
+	"github.com/smashwilson/collaborative-fiction/setting"
+	"github.com/smashwilson/collaborative-fiction/storage"
+)
 
 var (
 	ts = template.Must(template.ParseFiles(
 		"templates/login.html",
-		"templates/snippet-form.html",
 		"templates/welcome.html",
+		"templates/story.html",
 	))
-	story  *Story
-	config Configuration
+	repo   storage.StoryRepository
+	config *setting.Settings
 )
 
 func useTemplate(ctx context.Context, templateName string, data interface{}) error {
@@ -52,20 +47,30 @@ func path(subpath string) string {
 }
 
 func loginHandler(ctx context.Context) error {
+	type providerLink struct {
+		Name    string
+		Display string
+	}
+
 	type context struct {
-		Root string
+		Root      string
+		Providers []providerLink
 	}
 
 	c := context{Root: config.Root}
+	for _, name := range config.EnabledProviders() {
+		c.Providers = append(c.Providers, providerLink{Name: name, Display: providerDisplayNames[name]})
+	}
 	return useTemplate(ctx, "login.html", c)
 }
 
 func welcomeHandler(ctx context.Context) error {
 	type context struct {
-		Root   string
-		Name   string
-		Email  string
-		Avatar string
+		Root      string
+		Name      string
+		Email     string
+		Avatar    string
+		CSRFToken string
 	}
 
 	must := func(str string, err error) string {
@@ -75,45 +80,103 @@ func welcomeHandler(ctx context.Context) error {
 		return str
 	}
 
+	csrfToken, err := session.CSRFToken(ctx)
+	if err != nil {
+		log.Printf("Unable to mint CSRF token: %v", err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
+	}
+
 	c := context{
-		Root:   config.Root,
-		Name:   must(UserName(ctx)),
-		Email:  must(UserEmail(ctx)),
-		Avatar: must(UserAvatar(ctx)),
+		Root:      config.Root,
+		Name:      must(UserName(ctx)),
+		Email:     must(UserEmail(ctx)),
+		Avatar:    must(UserAvatar(ctx)),
+		CSRFToken: csrfToken,
 	}
 	return useTemplate(ctx, "welcome.html", c)
 }
 
-func main() {
-	err := envconfig.Process("fiction", &config)
+func newStoryHandler(ctx context.Context) error {
+	valid, err := session.ValidateCSRF(ctx, ctx.HttpRequest().FormValue("csrf_token"))
 	if err != nil {
-		log.Fatalf("Error reading configuration: %v", err)
+		log.Printf("Unable to validate CSRF token: %v", err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
+	}
+	if !valid {
+		return goweb.Respond.WithStatus(ctx, http.StatusForbidden)
 	}
 
-	if config.BaseURL == "" {
-		config.BaseURL = "http://localhost:8080"
+	s, err := repo.CreateStory()
+	if err != nil {
+		log.Printf("Unable to create story: %v", err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
 	}
+	return goweb.Respond.WithRedirect(ctx, absURL("story/"+s.ID+"/live"))
+}
 
-	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
-	config.Root = strings.TrimRight(config.Root, "/")
+// loadRepository reads the layered Settings and opens the StoryRepository they describe. It is
+// shared by the server and the `dump` subcommand, which both need a repository but otherwise do
+// very different things with it.
+func loadRepository() (storage.StoryRepository, *setting.Settings, error) {
+	cfg, err := setting.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.StorageDataSource == "" {
+		if cfg.Storage == "sqlite" {
+			cfg.StorageDataSource = "fiction.db"
+		} else {
+			cfg.StorageDataSource = "stories"
+		}
+	}
+
+	r, err := storage.NewRepository(cfg.Storage, cfg.StorageDataSource)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, cfg, nil
+}
+
+func runServe(c *cli.Context) error {
+	var err error
+	repo, config, err = loadRepository()
+	if err != nil {
+		return err
+	}
 
 	// Summarize the currently active configuration settings, without dumping secrets.
 	log.Println("Current configuration:")
-	log.Printf("  base url: %s\n", config.BaseURL)
-	log.Printf("  root: %s\n", config.Root)
-	log.Printf("  Google key [%t] secret [%t]\n", config.GoogleKey != "", config.GoogleSecret != "")
-	log.Printf("  GitHub key [%t] secret [%t]\n", config.GitHubKey != "", config.GitHubSecret != "")
+	log.Print(config.Describe())
 
-	err = registerAuthRoutes()
-	if err != nil {
-		log.Fatalf("Unable to register auth routes: %v", err)
-		return
+	hub = newHub(repo)
+
+	if err := registerAuthRoutes(); err != nil {
+		return err
+	}
+
+	if err := registerStoryRoutes(); err != nil {
+		return err
 	}
 
 	goweb.Map("GET", path(""), loginHandler)
 	goweb.Map("GET", path("welcome"), welcomeHandler)
+	goweb.Map("POST", path("story"), newStoryHandler)
 
 	log.Println("Ready to serve.")
 
-	http.ListenAndServe(":8080", goweb.DefaultHttpHandler())
+	return http.ListenAndServe(":8080", goweb.DefaultHttpHandler())
+}
+
+func main() {
+	app := &cli.App{
+		Name:     "fiction",
+		Usage:    "a collaborative fiction server",
+		Action:   runServe,
+		Commands: []*cli.Command{dumpCommand},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,113 @@
+// Package models contains the domain types shared by collaborative-fiction's HTTP handlers,
+// live-collaboration hub, and storage backends.
+package models
+
+import (
+	"encoding/hex"
+	"html/template"
+	"time"
+
+	"github.com/gorilla/securecookie"
+
+	"github.com/smashwilson/collaborative-fiction/render"
+)
+
+// Story is a complete story, told by many people.
+type Story struct {
+	ID       string
+	Snippets []Snippet
+	Started  *time.Time
+	Finished *time.Time
+
+	// Draft is the Snippet currently being composed, if any author holds the turn.
+	Draft *Draft
+}
+
+// Snippet is a part of a Story told by a single author.
+type Snippet struct {
+	Author  string
+	Created time.Time
+	Content *string
+
+	// renderedHTML caches the result of rendering Content, populated lazily by RenderedHTML.
+	// Left unexported so it's excluded from JSON serialization and doesn't leak into storage.
+	renderedHTML template.HTML
+	rendered     bool
+}
+
+// RenderedHTML renders Content as sanitized CommonMark HTML for display, caching the result so
+// repeated calls (e.g. across page loads) don't re-parse the source. The raw source remains
+// available via Content for editing.
+func (snippet *Snippet) RenderedHTML() template.HTML {
+	if snippet.rendered {
+		return snippet.renderedHTML
+	}
+
+	if snippet.Content != nil {
+		snippet.renderedHTML = render.Render(*snippet.Content)
+	}
+	snippet.rendered = true
+	return snippet.renderedHTML
+}
+
+// Draft is an in-progress Snippet, not yet committed to its Story.
+type Draft struct {
+	Author  string
+	Content string
+}
+
+// NewStory begins an empty Story.
+func NewStory() *Story {
+	ts := time.Now()
+
+	return &Story{
+		ID:       hex.EncodeToString(securecookie.GenerateRandomKey(8)),
+		Started:  &ts,
+		Finished: nil,
+	}
+}
+
+// NewSnippet creates a new Snippet.
+func NewSnippet(author string, content *string) *Snippet {
+	return &Snippet{
+		Author:  author,
+		Created: time.Now(),
+		Content: content,
+	}
+}
+
+// AppendSnippet appends a new Snippet to an existing Story.
+func (story *Story) AppendSnippet(snippet Snippet) {
+	story.Snippets = append(story.Snippets, snippet)
+}
+
+// FinishStory marks a Story as completed.
+func (story *Story) FinishStory() {
+	ts := time.Now()
+	story.Finished = &ts
+}
+
+// BeginSnippet opens a new Draft for the given author, discarding any Draft already in progress.
+func (story *Story) BeginSnippet(author string) *Draft {
+	story.Draft = &Draft{Author: author}
+	return story.Draft
+}
+
+// CommitSnippet finalizes the Story's in-progress Draft as a new Snippet and appends it. It
+// returns nil if no Draft was in progress.
+func (story *Story) CommitSnippet() *Snippet {
+	if story.Draft == nil {
+		return nil
+	}
+
+	content := story.Draft.Content
+	snippet := NewSnippet(story.Draft.Author, &content)
+	story.AppendSnippet(*snippet)
+	story.Draft = nil
+	return snippet
+}
+
+// AbandonSnippet discards the Story's in-progress Draft without appending it.
+func (story *Story) AbandonSnippet() {
+	story.Draft = nil
+}
@@ -0,0 +1,39 @@
+// Package render converts snippet source text into sanitized, displayable HTML. It parses
+// CommonMark (with a handful of GFM-style extensions) via goldmark and then strips anything
+// outside a user-generated-content allowlist via bluemonday, so rendered snippets are safe to
+// embed in a template without further escaping.
+package render
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown is configured once with the extension set collaborative-fiction supports: GitHub
+// Flavored Markdown tables, strikethrough, and autolinking of bare URLs.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+)
+
+// policy sanitizes rendered HTML down to the UGC allowlist: the same tags and attributes sites
+// like GitHub permit in user-submitted Markdown, which is more than enough for a story snippet
+// and excludes anything that could run script or load remote content.
+var policy = bluemonday.UGCPolicy()
+
+// Render parses source as CommonMark and sanitizes the result, returning HTML safe to embed
+// directly in a template.
+func Render(source string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
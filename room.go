@@ -0,0 +1,343 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/context"
+
+	"github.com/smashwilson/collaborative-fiction/models"
+	"github.com/smashwilson/collaborative-fiction/storage"
+)
+
+// turnLeaseTimeout is how long an author may hold the turn lock without sending a
+// snippetTyping event before another participant is allowed to bump them.
+const turnLeaseTimeout = 2 * time.Minute
+
+// upgrader negotiates the WebSocket handshake for live story connections.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// hub is the process-wide registry of live Rooms, keyed by story ID. It is initialized in
+// main() once the StoryRepository is available.
+var hub *Hub
+
+// event is a message broadcast to every participant connected to a Room.
+type event struct {
+	Type    string `json:"type"`
+	Author  string `json:"author,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// Hub brokers access to the live Rooms backing in-progress Stories.
+type Hub struct {
+	repo storage.StoryRepository
+
+	mu    sync.Mutex
+	rooms map[string]*Room
+}
+
+func newHub(repo storage.StoryRepository) *Hub {
+	return &Hub{repo: repo, rooms: make(map[string]*Room)}
+}
+
+// roomFor returns the Room for story, creating one if this is the first participant to join.
+func (hub *Hub) roomFor(story *models.Story) *Room {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	room, ok := hub.rooms[story.ID]
+	if !ok {
+		room = newRoom(story, hub.repo, hub)
+		hub.rooms[story.ID] = room
+		go room.run()
+	}
+	return room
+}
+
+// forgetRoom removes story's Room from the registry, once it has no participants left. It is
+// called by Room.run when its last client disconnects, so an idle Room doesn't pin its goroutine
+// and Story in memory for the life of the process.
+func (hub *Hub) forgetRoom(storyID string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.rooms, storyID)
+}
+
+// client is a single participant's WebSocket connection to a Room.
+type client struct {
+	name  string
+	email string
+	conn  *websocket.Conn
+	send  chan event
+}
+
+// Room brokers turn-taking and event broadcast among every client connected to a single Story.
+// mu guards both the turn lock and story.Draft: every goroutine that reads or mutates either
+// (room.run, on disconnect, and every client's readPump) must hold it first.
+type Room struct {
+	story *models.Story
+	repo  storage.StoryRepository
+	hub   *Hub
+
+	mu            sync.Mutex
+	turnAuthor    string
+	turnExpiresAt time.Time
+
+	clients    map[*client]bool
+	register   chan *client
+	unregister chan *client
+	broadcast  chan event
+}
+
+func newRoom(story *models.Story, repo storage.StoryRepository, hub *Hub) *Room {
+	return &Room{
+		story:      story,
+		repo:       repo,
+		hub:        hub,
+		clients:    make(map[*client]bool),
+		register:   make(chan *client),
+		unregister: make(chan *client),
+		broadcast:  make(chan event),
+	}
+}
+
+// run pumps registrations, departures, and broadcasts for the Room. It is meant to be started
+// with `go room.run()` and exits once the last participant disconnects, deregistering itself
+// from its Hub.
+func (room *Room) run() {
+	for {
+		select {
+		case c := <-room.register:
+			room.clients[c] = true
+		case c := <-room.unregister:
+			if _, ok := room.clients[c]; ok {
+				delete(room.clients, c)
+				close(c.send)
+				room.abandonDraft(c.name)
+			}
+			if len(room.clients) == 0 {
+				room.hub.forgetRoom(room.story.ID)
+				return
+			}
+		case e := <-room.broadcast:
+			for c := range room.clients {
+				select {
+				case c.send <- e:
+				default:
+					close(c.send)
+					delete(room.clients, c)
+				}
+			}
+		}
+	}
+}
+
+// claimTurnLocked attempts to give author the turn lock, bumping the current holder if their
+// lease has expired. It reports whether author now holds the turn. Callers must hold room.mu.
+func (room *Room) claimTurnLocked(author string) bool {
+	if room.turnAuthor != "" && room.turnAuthor != author && time.Now().Before(room.turnExpiresAt) {
+		return false
+	}
+
+	room.turnAuthor = author
+	room.turnExpiresAt = time.Now().Add(turnLeaseTimeout)
+	return true
+}
+
+// renewTurnLocked extends author's lease, as long as they still hold the turn. Callers must hold
+// room.mu.
+func (room *Room) renewTurnLocked(author string) bool {
+	if room.turnAuthor != author {
+		return false
+	}
+
+	room.turnExpiresAt = time.Now().Add(turnLeaseTimeout)
+	return true
+}
+
+// releaseTurnLocked clears the turn lock if author currently holds it. Callers must hold room.mu.
+func (room *Room) releaseTurnLocked(author string) {
+	if room.turnAuthor == author {
+		room.turnAuthor = ""
+		room.turnExpiresAt = time.Time{}
+	}
+}
+
+// beginDraft claims the turn for author and opens a new Draft, reporting whether it succeeded.
+func (room *Room) beginDraft(author string) bool {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if !room.claimTurnLocked(author) {
+		return false
+	}
+	room.story.BeginSnippet(author)
+	return true
+}
+
+// updateDraft renews author's lease and updates the in-progress Draft's content, as long as
+// author still holds the turn.
+func (room *Room) updateDraft(author, content string) bool {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if !room.renewTurnLocked(author) {
+		return false
+	}
+	if room.story.Draft != nil {
+		room.story.Draft.Content = content
+	}
+	return true
+}
+
+// commitDraft renews author's lease, finalizes the in-progress Draft as a Snippet, and releases
+// the turn. It reports false (with a nil Snippet) if author didn't hold the turn; otherwise it
+// reports true, with the committed Snippet or nil if no Draft was in progress.
+func (room *Room) commitDraft(author, content string) (*models.Snippet, bool) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if !room.renewTurnLocked(author) {
+		return nil, false
+	}
+	if room.story.Draft != nil {
+		room.story.Draft.Content = content
+	}
+	snippet := room.story.CommitSnippet()
+	room.releaseTurnLocked(author)
+	return snippet, true
+}
+
+// abandonDraft discards the in-progress Draft if author is the one who holds it, and releases
+// the turn. It reports whether a Draft was actually discarded, so callers can avoid broadcasting
+// a spoofed abandon for someone else's in-progress snippet.
+func (room *Room) abandonDraft(author string) bool {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	abandoned := room.story.Draft != nil && room.story.Draft.Author == author
+	if abandoned {
+		room.story.AbandonSnippet()
+	}
+	room.releaseTurnLocked(author)
+	return abandoned
+}
+
+func (c *client) readPump(room *Room) {
+	defer func() {
+		room.unregister <- c
+		c.conn.Close()
+	}()
+
+	for {
+		var incoming event
+		if err := c.conn.ReadJSON(&incoming); err != nil {
+			return
+		}
+		incoming.Author = c.name
+
+		switch incoming.Type {
+		case "snippetStarted":
+			if !room.beginDraft(c.name) {
+				continue
+			}
+			room.broadcast <- incoming
+		case "snippetTyping":
+			if !room.updateDraft(c.name, incoming.Content) {
+				continue
+			}
+			room.broadcast <- incoming
+		case "snippetCommitted":
+			snippet, ok := room.commitDraft(c.name, incoming.Content)
+			if !ok {
+				continue
+			}
+			if snippet != nil {
+				if err := room.repo.AppendSnippet(room.story.ID, *snippet); err != nil {
+					log.Printf("Unable to persist snippet for story [%s]: %v", room.story.ID, err)
+				}
+			}
+			room.broadcast <- incoming
+		case "snippetAbandoned":
+			if !room.abandonDraft(c.name) {
+				continue
+			}
+			room.broadcast <- incoming
+		}
+	}
+}
+
+func (c *client) writePump() {
+	defer c.conn.Close()
+
+	for e := range c.send {
+		if err := c.conn.WriteJSON(e); err != nil {
+			return
+		}
+	}
+}
+
+// storyLiveHandler upgrades an authenticated request to a WebSocket and joins the caller to the
+// live Room for the requested Story.
+func storyLiveHandler(ctx context.Context) error {
+	storyID := ctx.PathValue("id")
+	story, err := repo.LoadStory(storyID)
+	if err == storage.ErrStoryNotFound {
+		return goweb.Respond.WithStatus(ctx, http.StatusNotFound)
+	}
+	if err != nil {
+		log.Printf("Unable to load story [%s]: %v", storyID, err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
+	}
+
+	name, err := UserName(ctx)
+	if err != nil || name == "" {
+		return goweb.Respond.WithStatus(ctx, http.StatusUnauthorized)
+	}
+	email, _ := UserEmail(ctx)
+
+	conn, err := upgrader.Upgrade(ctx.HttpResponseWriter(), ctx.HttpRequest(), nil)
+	if err != nil {
+		log.Printf("Unable to upgrade connection for story [%s]: %v", storyID, err)
+		return nil
+	}
+
+	room := hub.roomFor(story)
+	c := &client{name: name, email: email, conn: conn, send: make(chan event, 16)}
+	room.register <- c
+
+	go c.writePump()
+	c.readPump(room)
+
+	return nil
+}
+
+// storyHandler renders the read-only view of a Story, with each Snippet's content rendered from
+// CommonMark to sanitized HTML.
+func storyHandler(ctx context.Context) error {
+	storyID := ctx.PathValue("id")
+	story, err := repo.LoadStory(storyID)
+	if err == storage.ErrStoryNotFound {
+		return goweb.Respond.WithStatus(ctx, http.StatusNotFound)
+	}
+	if err != nil {
+		log.Printf("Unable to load story [%s]: %v", storyID, err)
+		return goweb.Respond.WithStatus(ctx, http.StatusInternalServerError)
+	}
+
+	return useTemplate(ctx, "story.html", story)
+}
+
+// registerStoryRoutes wires up the live-collaboration and story-viewing endpoints.
+func registerStoryRoutes() error {
+	goweb.Map("GET", path("story/{id}"), storyHandler)
+	goweb.Map("GET", path("story/{id}/live"), storyLiveHandler)
+	return nil
+}
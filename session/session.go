@@ -0,0 +1,131 @@
+// Package session manages authenticated user identity and CSRF tokens through gorilla/sessions,
+// so handlers never touch cookies directly.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/goweb/context"
+)
+
+const (
+	sessionName = "fiction-session"
+	nameKey     = "name"
+	emailKey    = "email"
+	avatarKey   = "avatar"
+	csrfKey     = "csrf"
+)
+
+// Store backs every session this package manages. Init must be called before any handler calls
+// CurrentUser, SignIn, SignOut, CSRFToken, or ValidateCSRF.
+var Store sessions.Store
+
+// Init builds Store as a gorilla/sessions CookieStore from an ordered list of (hashKey,
+// blockKey) pairs, flattened: hash0, block0, hash1, block1, .... The first pair signs and
+// encrypts new sessions; any later pairs are accepted only so sessions issued under a previous
+// pair keep validating while it is rotated out.
+//
+// gorilla/sessions defaults new CookieStores to Secure cookies with SameSite=None, which browsers
+// refuse to store or send over the plain HTTP this server speaks. Override both so the default,
+// TLS-less deployment this repo ships actually keeps sessions alive.
+func Init(keyPairs ...[]byte) {
+	store := sessions.NewCookieStore(keyPairs...)
+	store.Options.Secure = false
+	store.Options.SameSite = http.SameSiteLaxMode
+	Store = store
+}
+
+// User is the identity carried in an authenticated session.
+type User struct {
+	Name   string
+	Email  string
+	Avatar string
+}
+
+func get(ctx context.Context) (*sessions.Session, error) {
+	return Store.Get(ctx.HttpRequest(), sessionName)
+}
+
+// CurrentUser returns the identity of the signed-in user, or nil if no one is signed in.
+func CurrentUser(ctx context.Context) (*User, error) {
+	sess, err := get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := sess.Values[nameKey].(string)
+	if name == "" {
+		return nil, nil
+	}
+
+	email, _ := sess.Values[emailKey].(string)
+	avatar, _ := sess.Values[avatarKey].(string)
+	return &User{Name: name, Email: email, Avatar: avatar}, nil
+}
+
+// SignIn stores user's identity in the session and persists it to the response.
+func SignIn(ctx context.Context, user *User) error {
+	sess, err := get(ctx)
+	if err != nil {
+		return err
+	}
+
+	sess.Values[nameKey] = user.Name
+	sess.Values[emailKey] = user.Email
+	sess.Values[avatarKey] = user.Avatar
+	return sess.Save(ctx.HttpRequest(), ctx.HttpResponseWriter())
+}
+
+// SignOut destroys the current session, signing its user out.
+func SignOut(ctx context.Context) error {
+	sess, err := get(ctx)
+	if err != nil {
+		return err
+	}
+
+	sess.Options.MaxAge = -1
+	return sess.Save(ctx.HttpRequest(), ctx.HttpResponseWriter())
+}
+
+// CSRFToken returns the CSRF token for the current session, minting and persisting one the
+// first time it is requested.
+func CSRFToken(ctx context.Context) (string, error) {
+	sess, err := get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if token, ok := sess.Values[csrfKey].(string); ok && token != "" {
+		return token, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.URLEncoding.EncodeToString(buf)
+
+	sess.Values[csrfKey] = token
+	if err := sess.Save(ctx.HttpRequest(), ctx.HttpResponseWriter()); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateCSRF reports whether token matches the CSRF token stored in the current session.
+func ValidateCSRF(ctx context.Context, token string) (bool, error) {
+	sess, err := get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	expected, _ := sess.Values[csrfKey].(string)
+	if token == "" || expected == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1, nil
+}
@@ -0,0 +1,153 @@
+// Package setting loads collaborative-fiction's configuration by layering built-in defaults, an
+// INI file at custom/conf/app.ini, and "FICTION_"-prefixed environment variable overrides, in
+// that order. This mirrors the approach Gogs takes in its modules/setting package.
+package setting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/ini.v1"
+)
+
+// ConfigPath is the location of the optional INI configuration file. It is a var so tests and
+// alternate deployments can point it elsewhere.
+var ConfigPath = "custom/conf/app.ini"
+
+// OAuthProvider is one provider's entry under the [oauth] section.
+type OAuthProvider struct {
+	Enabled      bool
+	ClientID     string
+	ClientSecret string
+}
+
+// Configured reports whether provider has everything it needs to be offered to users.
+func (p *OAuthProvider) Configured() bool {
+	return p != nil && p.Enabled && p.ClientID != "" && p.ClientSecret != ""
+}
+
+// Settings holds collaborative-fiction's full runtime configuration.
+type Settings struct {
+	BaseURL string
+	Root    string
+
+	Storage           string
+	StorageDataSource string
+
+	OAuthEnabled   bool
+	OAuthProviders map[string]*OAuthProvider
+}
+
+// defaults returns the built-in configuration, applied before app.ini and the environment are
+// consulted.
+func defaults() *Settings {
+	return &Settings{
+		BaseURL:      "http://localhost:8080",
+		OAuthEnabled: true,
+		OAuthProviders: map[string]*OAuthProvider{
+			"google": {Enabled: true},
+			"github": {Enabled: true},
+		},
+	}
+}
+
+// Load builds the Settings by layering the INI file at ConfigPath (if present) and then
+// "FICTION_"-prefixed environment variables over the built-in defaults.
+func Load() (*Settings, error) {
+	s := defaults()
+
+	if err := s.loadINI(); err != nil {
+		return nil, err
+	}
+
+	if err := envconfig.Process("fiction", s); err != nil {
+		return nil, err
+	}
+
+	s.BaseURL = strings.TrimRight(s.BaseURL, "/")
+	s.Root = strings.TrimRight(s.Root, "/")
+
+	return s, nil
+}
+
+func (s *Settings) loadINI() error {
+	cfg, err := ini.LooseLoad(ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	server := cfg.Section("server")
+	if key := server.Key("BASE_URL").String(); key != "" {
+		s.BaseURL = key
+	}
+	if key := server.Key("ROOT").String(); key != "" {
+		s.Root = key
+	}
+
+	storage := cfg.Section("storage")
+	if key := storage.Key("BACKEND").String(); key != "" {
+		s.Storage = key
+	}
+	if key := storage.Key("DATA_SOURCE").String(); key != "" {
+		s.StorageDataSource = key
+	}
+
+	oauth := cfg.Section("oauth")
+	s.OAuthEnabled = oauth.Key("ENABLED").MustBool(s.OAuthEnabled)
+
+	for _, section := range cfg.Sections() {
+		name := strings.TrimPrefix(section.Name(), "oauth.")
+		if name == section.Name() || name == "" {
+			continue
+		}
+
+		provider, ok := s.OAuthProviders[name]
+		if !ok {
+			provider = &OAuthProvider{}
+			s.OAuthProviders[name] = provider
+		}
+
+		provider.Enabled = section.Key("ENABLED").MustBool(provider.Enabled)
+		if v := section.Key("CLIENT_ID").String(); v != "" {
+			provider.ClientID = v
+		}
+		if v := section.Key("CLIENT_SECRET").String(); v != "" {
+			provider.ClientSecret = v
+		}
+	}
+
+	return nil
+}
+
+// EnabledProviders returns the name of every OAuth provider that is both individually enabled
+// and fully configured, sorted for stable iteration.
+func (s *Settings) EnabledProviders() []string {
+	if !s.OAuthEnabled {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.OAuthProviders))
+	for name, provider := range s.OAuthProviders {
+		if provider.Configured() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe renders a human-readable summary of the currently active configuration, without
+// exposing secrets, suitable for startup logging.
+func (s *Settings) Describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  base url: %s\n", s.BaseURL)
+	fmt.Fprintf(&b, "  root: %s\n", s.Root)
+	fmt.Fprintf(&b, "  storage backend [%s] data source [%s]\n", s.Storage, s.StorageDataSource)
+	fmt.Fprintf(&b, "  oauth enabled: %t\n", s.OAuthEnabled)
+	for _, name := range s.EnabledProviders() {
+		fmt.Fprintf(&b, "  oauth provider [%s] enabled\n", name)
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smashwilson/collaborative-fiction/models"
+)
+
+// FilesystemRepository is a StoryRepository that persists each Story as its own JSON file in a
+// directory on disk.
+type FilesystemRepository struct {
+	dir string
+}
+
+// NewFilesystemRepository creates (if necessary) dir and returns a FilesystemRepository backed
+// by it.
+func NewFilesystemRepository(dir string) (*FilesystemRepository, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FilesystemRepository{dir: dir}, nil
+}
+
+func (repo *FilesystemRepository) path(id string) string {
+	return filepath.Join(repo.dir, id+".json")
+}
+
+func (repo *FilesystemRepository) write(story *models.Story) error {
+	data, err := json.MarshalIndent(story, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(repo.path(story.ID), data, 0644)
+}
+
+// CreateStory begins and persists a new, empty Story.
+func (repo *FilesystemRepository) CreateStory() (*models.Story, error) {
+	story := models.NewStory()
+	if err := repo.write(story); err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+// LoadStory retrieves a Story by ID, or ErrStoryNotFound if none exists.
+func (repo *FilesystemRepository) LoadStory(id string) (*models.Story, error) {
+	data, err := os.ReadFile(repo.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrStoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	story := &models.Story{}
+	if err := json.Unmarshal(data, story); err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+// ListStories returns up to limit Stories, skipping the first offset, most recently started
+// first. If author is non-empty, only Stories containing a Snippet by that author are returned.
+func (repo *FilesystemRepository) ListStories(author string, limit, offset int) ([]*models.Story, error) {
+	entries, err := os.ReadDir(repo.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var stories []*models.Story
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		story, err := repo.LoadStory(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+
+		if author != "" && !hasAuthor(story, author) {
+			continue
+		}
+		stories = append(stories, story)
+	}
+
+	sort.Slice(stories, func(i, j int) bool {
+		return stories[i].Started.After(*stories[j].Started)
+	})
+
+	if offset >= len(stories) {
+		return []*models.Story{}, nil
+	}
+	stories = stories[offset:]
+
+	if limit > 0 && limit < len(stories) {
+		stories = stories[:limit]
+	}
+	return stories, nil
+}
+
+// ListStoryIDs is like ListStories, but returns only IDs. Each Story is still decoded in full to
+// check its Started time and (if author is set) its Snippets, but only one is held in memory at
+// a time, rather than the whole matching set.
+func (repo *FilesystemRepository) ListStoryIDs(author string, limit, offset int) ([]string, error) {
+	entries, err := os.ReadDir(repo.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type stub struct {
+		id      string
+		started time.Time
+	}
+
+	var stubs []stub
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		story, err := repo.LoadStory(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if author != "" && !hasAuthor(story, author) {
+			continue
+		}
+		stubs = append(stubs, stub{id: id, started: *story.Started})
+	}
+
+	sort.Slice(stubs, func(i, j int) bool {
+		return stubs[i].started.After(stubs[j].started)
+	})
+
+	if offset >= len(stubs) {
+		return []string{}, nil
+	}
+	stubs = stubs[offset:]
+
+	if limit > 0 && limit < len(stubs) {
+		stubs = stubs[:limit]
+	}
+
+	ids := make([]string, len(stubs))
+	for i, s := range stubs {
+		ids[i] = s.id
+	}
+	return ids, nil
+}
+
+func hasAuthor(story *models.Story, author string) bool {
+	for _, snippet := range story.Snippets {
+		if snippet.Author == author {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendSnippet persists a new Snippet onto the end of the Story identified by storyID.
+func (repo *FilesystemRepository) AppendSnippet(storyID string, snippet models.Snippet) error {
+	story, err := repo.LoadStory(storyID)
+	if err != nil {
+		return err
+	}
+
+	story.AppendSnippet(snippet)
+	return repo.write(story)
+}
+
+// FinishStory marks the Story identified by id as completed.
+func (repo *FilesystemRepository) FinishStory(id string) error {
+	story, err := repo.LoadStory(id)
+	if err != nil {
+		return err
+	}
+
+	story.FinishStory()
+	return repo.write(story)
+}
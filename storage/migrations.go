@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+// migration describes one schema change applied to the SQLite backend. Migrations run in order
+// and are never reordered or removed once released.
+type migration struct {
+	description string
+	migrate     func(*xorm.Engine) error
+}
+
+// migrations is the ordered history of schema changes applied to the SQLite backend. Append to
+// this slice; never edit or remove an existing entry.
+var migrations = []migration{
+	{
+		description: "create story and snippet tables",
+		migrate: func(engine *xorm.Engine) error {
+			return engine.Sync2(new(storyRow), new(snippetRow))
+		},
+	},
+}
+
+// schemaVersion records how many migrations have been applied to a SQLite database.
+type schemaVersion struct {
+	ID      int64 `xorm:"pk 'id'"`
+	Version int
+}
+
+func (schemaVersion) TableName() string { return "schema_version" }
+
+// migrate brings engine's schema up to date, applying any migrations not yet recorded.
+func migrate(engine *xorm.Engine) error {
+	if err := engine.Sync2(new(schemaVersion)); err != nil {
+		return err
+	}
+
+	version := &schemaVersion{ID: 1}
+	has, err := engine.Get(version)
+	if err != nil {
+		return err
+	}
+	if !has {
+		if _, err := engine.Insert(version); err != nil {
+			return err
+		}
+	}
+
+	for version.Version < len(migrations) {
+		m := migrations[version.Version]
+		if err := m.migrate(engine); err != nil {
+			return fmt.Errorf("storage: migration %d (%s): %w", version.Version, m.description, err)
+		}
+
+		version.Version++
+		if _, err := engine.ID(version.ID).Cols("version").Update(version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
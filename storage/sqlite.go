@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"xorm.io/xorm"
+
+	"github.com/smashwilson/collaborative-fiction/models"
+)
+
+// storyRow is the persisted representation of a models.Story, one row per story.
+type storyRow struct {
+	ID       string `xorm:"pk 'id'"`
+	Started  time.Time
+	Finished *time.Time
+}
+
+func (storyRow) TableName() string { return "story" }
+
+// snippetRow is the persisted representation of a models.Snippet, ordered within its Story by
+// Seq.
+type snippetRow struct {
+	ID      int64  `xorm:"pk autoincr 'id'"`
+	StoryID string `xorm:"index 'story_id'"`
+	Seq     int
+	Author  string
+	Created time.Time
+	Content string
+}
+
+func (snippetRow) TableName() string { return "snippet" }
+
+// SQLiteRepository is a StoryRepository backed by a SQLite database, managed through xorm.
+type SQLiteRepository struct {
+	engine *xorm.Engine
+}
+
+// NewSQLiteRepository opens (creating if necessary) a SQLite database at dataSource and brings
+// its schema up to date.
+func NewSQLiteRepository(dataSource string) (*SQLiteRepository, error) {
+	engine, err := xorm.NewEngine("sqlite3", dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(engine); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteRepository{engine: engine}, nil
+}
+
+// CreateStory begins and persists a new, empty Story.
+func (repo *SQLiteRepository) CreateStory() (*models.Story, error) {
+	story := models.NewStory()
+
+	row := &storyRow{ID: story.ID, Started: *story.Started}
+	if _, err := repo.engine.Insert(row); err != nil {
+		return nil, err
+	}
+	return story, nil
+}
+
+// LoadStory retrieves a Story by ID, or ErrStoryNotFound if none exists.
+func (repo *SQLiteRepository) LoadStory(id string) (*models.Story, error) {
+	row := &storyRow{ID: id}
+	has, err := repo.engine.Get(row)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrStoryNotFound
+	}
+
+	var snippetRows []snippetRow
+	if err := repo.engine.Where("story_id = ?", id).Asc("seq").Find(&snippetRows); err != nil {
+		return nil, err
+	}
+
+	story := &models.Story{ID: row.ID, Started: &row.Started, Finished: row.Finished}
+	for _, s := range snippetRows {
+		content := s.Content
+		story.Snippets = append(story.Snippets, models.Snippet{
+			Author:  s.Author,
+			Created: s.Created,
+			Content: &content,
+		})
+	}
+	return story, nil
+}
+
+// listStoryRows fetches the storyRows matching author, limit, and offset, with the same
+// ordering and pagination semantics as ListStories.
+func (repo *SQLiteRepository) listStoryRows(author string, limit, offset int) ([]storyRow, error) {
+	var rows []storyRow
+
+	session := repo.engine.Desc("started")
+	if limit > 0 {
+		session = session.Limit(limit, offset)
+	}
+	if author != "" {
+		session = session.Join("INNER", "snippet", "snippet.story_id = story.id").
+			Where("snippet.author = ?", author).Distinct("story.id", "story.started", "story.finished")
+	}
+
+	if err := session.Find(&rows); err != nil {
+		return nil, err
+	}
+
+	// With no limit, xorm.Limit is never called, so offset must still be applied by hand.
+	if limit <= 0 {
+		if offset >= len(rows) {
+			rows = nil
+		} else {
+			rows = rows[offset:]
+		}
+	}
+
+	return rows, nil
+}
+
+// ListStories returns up to limit Stories, skipping the first offset, most recently started
+// first. If author is non-empty, only Stories containing a Snippet by that author are returned.
+func (repo *SQLiteRepository) ListStories(author string, limit, offset int) ([]*models.Story, error) {
+	rows, err := repo.listStoryRows(author, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	stories := make([]*models.Story, 0, len(rows))
+	for _, row := range rows {
+		story, err := repo.LoadStory(row.ID)
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, story)
+	}
+	return stories, nil
+}
+
+// ListStoryIDs is like ListStories, but returns only IDs: it never touches the snippet table, so
+// callers that want to process Stories one at a time (e.g. `fiction dump`) can enumerate them
+// without loading any Snippet content into memory.
+func (repo *SQLiteRepository) ListStoryIDs(author string, limit, offset int) ([]string, error) {
+	rows, err := repo.listStoryRows(author, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids, nil
+}
+
+// AppendSnippet persists a new Snippet onto the end of the Story identified by storyID.
+func (repo *SQLiteRepository) AppendSnippet(storyID string, snippet models.Snippet) error {
+	count, err := repo.engine.Where("story_id = ?", storyID).Count(new(snippetRow))
+	if err != nil {
+		return err
+	}
+
+	row := &snippetRow{
+		StoryID: storyID,
+		Seq:     int(count),
+		Author:  snippet.Author,
+		Created: snippet.Created,
+		Content: *snippet.Content,
+	}
+	_, err = repo.engine.Insert(row)
+	return err
+}
+
+// FinishStory marks the Story identified by id as completed.
+func (repo *SQLiteRepository) FinishStory(id string) error {
+	ts := time.Now()
+	_, err := repo.engine.ID(id).Cols("finished").Update(&storyRow{Finished: &ts})
+	return err
+}
@@ -0,0 +1,53 @@
+// Package storage persists Stories behind a pluggable StoryRepository interface, so the HTTP
+// handlers and live-collaboration hub never need to know whether a Story lives in SQLite or on
+// the filesystem.
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smashwilson/collaborative-fiction/models"
+)
+
+// ErrStoryNotFound is returned by a StoryRepository when no Story exists for a given ID.
+var ErrStoryNotFound = errors.New("storage: story not found")
+
+// StoryRepository persists and retrieves Stories.
+type StoryRepository interface {
+	// CreateStory begins and persists a new, empty Story.
+	CreateStory() (*models.Story, error)
+
+	// LoadStory retrieves a Story by ID, or ErrStoryNotFound if none exists.
+	LoadStory(id string) (*models.Story, error)
+
+	// ListStories returns up to limit Stories, skipping the first offset, most recently
+	// started first. If author is non-empty, only Stories containing a Snippet by that
+	// author are returned.
+	ListStories(author string, limit, offset int) ([]*models.Story, error)
+
+	// ListStoryIDs is like ListStories, but returns only IDs, without loading any Story's
+	// Snippets. It lets callers that want to process Stories one at a time (e.g. `fiction
+	// dump`) enumerate them without holding every Snippet's content in memory at once.
+	ListStoryIDs(author string, limit, offset int) ([]string, error)
+
+	// AppendSnippet persists a new Snippet onto the end of the Story identified by storyID.
+	AppendSnippet(storyID string, snippet models.Snippet) error
+
+	// FinishStory marks the Story identified by id as completed.
+	FinishStory(id string) error
+}
+
+// NewRepository constructs the StoryRepository selected by backend ("sqlite" or "fs"),
+// persisting to dataSource (a SQLite DSN or a directory, respectively). An empty backend
+// defaults to "fs".
+func NewRepository(backend, dataSource string) (StoryRepository, error) {
+	switch backend {
+	case "", "fs":
+		return NewFilesystemRepository(dataSource)
+	case "sqlite":
+		return NewSQLiteRepository(dataSource)
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", backend)
+	}
+}